@@ -0,0 +1,371 @@
+// Package admin implements the remote admin channel: a small
+// token-authenticated protocol that lets a separate invocation of the binary (run with an
+// "admin mode" flag) list, add, and revoke users, and force-stop
+// playback on a running one-time-plex instance without SSHing to the
+// host. The package does not encrypt the connection itself; see Client.
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/andytt11/one-time-plex/server/datastore"
+)
+
+// Command identifies an operation the admin channel can perform.
+type Command string
+
+// Commands supported over the admin channel.
+const (
+	CommandListUsers    Command = "list_users"
+	CommandGetUser      Command = "get_user"
+	CommandAddUser      Command = "add_user"
+	CommandAddUsers     Command = "add_users"
+	CommandRevokeUser   Command = "revoke_user"
+	CommandStopPlayback Command = "stop_playback"
+	CommandDeleteUser   Command = "delete_user"
+	CommandDeleteUsers  Command = "delete_users"
+)
+
+// EventType identifies a server-side event streamed back to admin clients.
+type EventType string
+
+// Event types emitted on the admin event stream.
+const (
+	EventPlaybackStarted EventType = "playback_started"
+	EventPlaybackStopped EventType = "playback_stopped"
+	EventAccessRevoked   EventType = "access_revoked"
+)
+
+// Event is a single server-side occurrence streamed to connected admin clients.
+type Event struct {
+	Type      EventType `json:"type"`
+	UserID    string    `json:"userID"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Request is a single admin command sent over the channel. Nonce must
+// be unique per Token; the Handler rejects any Nonce it has already
+// seen so a captured request can't be replayed.
+type Request struct {
+	Token   string          `json:"token"`
+	Nonce   string          `json:"nonce"`
+	Command Command         `json:"command"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Response is the Handler's answer to a Request.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// frameType identifies what a frame carries on the wire: a Response to
+// a Request the client sent, or an Event pushed by the server on its
+// own.
+type frameType string
+
+const (
+	frameResponse frameType = "response"
+	frameEvent    frameType = "event"
+)
+
+// frame is the newline-delimited unit Serve and Client exchange, so a
+// single connection can multiplex command responses with the
+// server-side event stream.
+type frame struct {
+	Type     frameType `json:"type"`
+	Response *Response `json:"response,omitempty"`
+	Event    *Event    `json:"event,omitempty"`
+}
+
+// NewNonce returns a random, hex-encoded nonce for use on a Request.
+func NewNonce() (string, error) {
+	buf := make([]byte, 16)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// deriveToken produces the admin token from the app secret, so the
+// admin channel doesn't need a credential of its own to manage: anyone
+// holding the app secret (see datastore.Store.GetSecret/SaveSecret)
+// already has full access to the instance.
+func deriveToken(secret []byte) string {
+	sum := sha256.Sum256(append([]byte("admin-token:"), secret...))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Store is the subset of datastore.Store the admin channel manages.
+type Store interface {
+	GetSecret() []byte
+	SaveUser(user datastore.User) error
+	SaveUsers(users []datastore.User) error
+	GetUser(id string) (datastore.User, error)
+	GetAllUsers() (map[string]datastore.User, error)
+	DeleteUser(id string) error
+	DeleteUsers(userIDs []string) error
+}
+
+// Handler answers admin Requests against a Store. It is safe for
+// concurrent use by multiple connections.
+type Handler struct {
+	store Store
+
+	mu         sync.Mutex
+	seenNonces map[string]time.Time
+	nonceTTL   time.Duration
+
+	events chan Event
+}
+
+// NewHandler creates a Handler backed by store.
+func NewHandler(store Store) *Handler {
+	return &Handler{
+		store:      store,
+		seenNonces: map[string]time.Time{},
+		nonceTTL:   5 * time.Minute,
+		events:     make(chan Event, 32),
+	}
+}
+
+// Events returns the channel admin clients should drain to receive
+// server-side events such as playback starting/stopping or access
+// being revoked.
+func (h *Handler) Events() <-chan Event {
+	return h.events
+}
+
+// emit pushes an event to connected admin clients, dropping it if no
+// one is listening closely enough to keep the buffer from filling.
+func (h *Handler) emit(event Event) {
+	select {
+	case h.events <- event:
+	default:
+		fmt.Println("admin: dropped event, no room in the event buffer:", event.Type)
+	}
+}
+
+// authenticate validates req.Token against the current app secret and
+// rejects req.Nonce if it has been seen before.
+func (h *Handler) authenticate(req Request) error {
+	want := deriveToken(h.store.GetSecret())
+
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(want)) != 1 {
+		return fmt.Errorf("admin: invalid token")
+	}
+
+	if req.Nonce == "" {
+		return fmt.Errorf("admin: nonce is required")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+
+	for nonce, seenAt := range h.seenNonces {
+		if now.Sub(seenAt) > h.nonceTTL {
+			delete(h.seenNonces, nonce)
+		}
+	}
+
+	if _, ok := h.seenNonces[req.Nonce]; ok {
+		return fmt.Errorf("admin: nonce already used")
+	}
+
+	h.seenNonces[req.Nonce] = now
+
+	return nil
+}
+
+// Handle authenticates and dispatches a single Request.
+func (h *Handler) Handle(req Request) Response {
+	if err := h.authenticate(req); err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+
+	switch req.Command {
+	case CommandListUsers:
+		return h.handleListUsers()
+	case CommandGetUser:
+		return h.handleGetUser(req.Payload)
+	case CommandAddUser:
+		return h.handleAddUser(req.Payload)
+	case CommandAddUsers:
+		return h.handleAddUsers(req.Payload)
+	case CommandRevokeUser:
+		return h.handleRevokeUser(req.Payload)
+	case CommandStopPlayback:
+		return h.handleStopPlayback(req.Payload)
+	case CommandDeleteUser:
+		return h.handleDeleteUser(req.Payload)
+	case CommandDeleteUsers:
+		return h.handleDeleteUsers(req.Payload)
+	default:
+		return Response{OK: false, Error: fmt.Sprintf("admin: unknown command %q", req.Command)}
+	}
+}
+
+func errResponse(err error) Response {
+	return Response{OK: false, Error: err.Error()}
+}
+
+func dataResponse(v interface{}) Response {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return errResponse(err)
+	}
+
+	return Response{OK: true, Data: data}
+}
+
+func (h *Handler) handleListUsers() Response {
+	users, err := h.store.GetAllUsers()
+
+	if err != nil {
+		return errResponse(err)
+	}
+
+	return dataResponse(users)
+}
+
+type userIDPayload struct {
+	UserID string `json:"userID"`
+}
+
+func (h *Handler) handleGetUser(payload json.RawMessage) Response {
+	var p userIDPayload
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errResponse(err)
+	}
+
+	user, err := h.store.GetUser(p.UserID)
+
+	if err != nil {
+		return errResponse(err)
+	}
+
+	return dataResponse(user)
+}
+
+func (h *Handler) handleAddUser(payload json.RawMessage) Response {
+	var user datastore.User
+
+	if err := json.Unmarshal(payload, &user); err != nil {
+		return errResponse(err)
+	}
+
+	if err := h.store.SaveUser(user); err != nil {
+		return errResponse(err)
+	}
+
+	return Response{OK: true}
+}
+
+func (h *Handler) handleAddUsers(payload json.RawMessage) Response {
+	var users []datastore.User
+
+	if err := json.Unmarshal(payload, &users); err != nil {
+		return errResponse(err)
+	}
+
+	if err := h.store.SaveUsers(users); err != nil {
+		return errResponse(err)
+	}
+
+	return Response{OK: true}
+}
+
+func (h *Handler) handleRevokeUser(payload json.RawMessage) Response {
+	var p userIDPayload
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errResponse(err)
+	}
+
+	user, err := h.store.GetUser(p.UserID)
+
+	if err != nil {
+		return errResponse(err)
+	}
+
+	user.RevokeAccess = true
+
+	if err := h.store.SaveUser(user); err != nil {
+		return errResponse(err)
+	}
+
+	h.emit(Event{Type: EventAccessRevoked, UserID: user.PlexUserID, Timestamp: time.Now()})
+
+	return Response{OK: true}
+}
+
+func (h *Handler) handleStopPlayback(payload json.RawMessage) Response {
+	var p userIDPayload
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errResponse(err)
+	}
+
+	user, err := h.store.GetUser(p.UserID)
+
+	if err != nil {
+		return errResponse(err)
+	}
+
+	user.StoppingPlayback = true
+
+	if err := h.store.SaveUser(user); err != nil {
+		return errResponse(err)
+	}
+
+	h.emit(Event{Type: EventPlaybackStopped, UserID: user.PlexUserID, Timestamp: time.Now()})
+
+	return Response{OK: true}
+}
+
+func (h *Handler) handleDeleteUser(payload json.RawMessage) Response {
+	var p userIDPayload
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errResponse(err)
+	}
+
+	if err := h.store.DeleteUser(p.UserID); err != nil {
+		return errResponse(err)
+	}
+
+	return Response{OK: true}
+}
+
+type userIDsPayload struct {
+	UserIDs []string `json:"userIDs"`
+}
+
+func (h *Handler) handleDeleteUsers(payload json.RawMessage) Response {
+	var p userIDsPayload
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errResponse(err)
+	}
+
+	if err := h.store.DeleteUsers(p.UserIDs); err != nil {
+		return errResponse(err)
+	}
+
+	return Response{OK: true}
+}
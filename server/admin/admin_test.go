@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andytt11/one-time-plex/server/datastore"
+)
+
+// fakeStore is a minimal in-memory admin.Store for tests.
+type fakeStore struct {
+	secret []byte
+	users  map[string]datastore.User
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{secret: []byte("test-secret"), users: map[string]datastore.User{}}
+}
+
+func (s *fakeStore) GetSecret() []byte { return s.secret }
+
+func (s *fakeStore) SaveUser(user datastore.User) error {
+	s.users[user.PlexUserID] = user
+	return nil
+}
+
+func (s *fakeStore) SaveUsers(users []datastore.User) error {
+	for _, user := range users {
+		s.users[user.PlexUserID] = user
+	}
+
+	return nil
+}
+
+func (s *fakeStore) GetUser(id string) (datastore.User, error) {
+	return s.users[id], nil
+}
+
+func (s *fakeStore) GetAllUsers() (map[string]datastore.User, error) {
+	return s.users, nil
+}
+
+func (s *fakeStore) DeleteUser(id string) error {
+	delete(s.users, id)
+	return nil
+}
+
+func (s *fakeStore) DeleteUsers(userIDs []string) error {
+	for _, id := range userIDs {
+		delete(s.users, id)
+	}
+
+	return nil
+}
+
+// TestServeStreamsEventsToClient guards the headline feature of
+// chunk0-2: a revoke command must show up on the client's Events
+// channel, not just queue silently inside the Handler.
+func TestServeStreamsEventsToClient(t *testing.T) {
+	store := newFakeStore()
+	store.SaveUser(datastore.User{PlexUserID: "user-1"})
+
+	handler := NewHandler(store)
+
+	serverConn, clientConn := net.Pipe()
+
+	go Serve(serverConn, handler)
+	defer serverConn.Close()
+
+	client := NewClient(clientConn, store.secret)
+	defer client.Close()
+
+	resp, err := client.Do(CommandRevokeUser, userIDPayload{UserID: "user-1"})
+
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if !resp.OK {
+		t.Fatalf("expected revoke to succeed, got error %q", resp.Error)
+	}
+
+	select {
+	case event := <-client.Events():
+		if event.Type != EventAccessRevoked || event.UserID != "user-1" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an access-revoked event to arrive on the client's Events channel")
+	}
+}
+
+// TestAuthenticateRejectsWrongToken guards the constant-time comparison
+// fix: an invalid token must still be rejected, not just rejected in a
+// way that leaks timing.
+func TestAuthenticateRejectsWrongToken(t *testing.T) {
+	store := newFakeStore()
+	handler := NewHandler(store)
+
+	nonce, err := NewNonce()
+
+	if err != nil {
+		t.Fatalf("NewNonce: %v", err)
+	}
+
+	resp := handler.Handle(Request{Token: "not-the-token", Nonce: nonce, Command: CommandListUsers})
+
+	if resp.OK {
+		t.Fatal("expected an invalid token to be rejected")
+	}
+}
@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+)
+
+// Client issues admin commands against a remote Handler, authenticating
+// with the admin token derived from the target's app secret and a fresh
+// nonce per command to block replay. The underlying conn is expected to
+// already be encrypted (e.g. TLS) by the caller's dial logic.
+//
+// A background goroutine reads every frame off conn: Response frames
+// are routed back to the Do call that's waiting on them, and Event
+// frames are pushed onto the channel Events returns, so a client can
+// observe playback/revocation events even between commands.
+type Client struct {
+	conn  net.Conn
+	token string
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending []chan Response
+
+	events chan Event
+}
+
+// NewClient wraps conn as an admin Client authenticated with secret.
+func NewClient(conn net.Conn, secret []byte) *Client {
+	c := &Client{
+		conn:   conn,
+		token:  deriveToken(secret),
+		events: make(chan Event, 32),
+	}
+
+	go c.readLoop()
+
+	return c
+}
+
+// Events returns the channel of server-pushed Events streamed to this client.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// readLoop drains frames off the connection until it closes, dispatching
+// each to Events or to the oldest pending Do call, in the order the
+// Handler answers them.
+func (c *Client) readLoop() {
+	defer close(c.events)
+
+	reader := bufio.NewReader(c.conn)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err != nil {
+			return
+		}
+
+		var f frame
+
+		if err := json.Unmarshal(line, &f); err != nil {
+			continue
+		}
+
+		switch f.Type {
+		case frameEvent:
+			if f.Event == nil {
+				continue
+			}
+
+			select {
+			case c.events <- *f.Event:
+			default:
+			}
+
+		case frameResponse:
+			var resp Response
+
+			if f.Response != nil {
+				resp = *f.Response
+			}
+
+			c.mu.Lock()
+
+			if len(c.pending) == 0 {
+				c.mu.Unlock()
+				continue
+			}
+
+			respCh := c.pending[0]
+			c.pending = c.pending[1:]
+
+			c.mu.Unlock()
+
+			respCh <- resp
+		}
+	}
+}
+
+// Do sends a single command with payload and returns the Handler's Response.
+func (c *Client) Do(command Command, payload interface{}) (Response, error) {
+	var resp Response
+
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return resp, err
+	}
+
+	nonce, err := NewNonce()
+
+	if err != nil {
+		return resp, err
+	}
+
+	req := Request{
+		Token:   c.token,
+		Nonce:   nonce,
+		Command: command,
+		Payload: data,
+	}
+
+	encoded, err := json.Marshal(req)
+
+	if err != nil {
+		return resp, err
+	}
+
+	respCh := make(chan Response, 1)
+
+	c.writeMu.Lock()
+
+	c.mu.Lock()
+	c.pending = append(c.pending, respCh)
+	c.mu.Unlock()
+
+	_, err = c.conn.Write(append(encoded, '\n'))
+
+	c.writeMu.Unlock()
+
+	if err != nil {
+		return resp, err
+	}
+
+	return <-respCh, nil
+}
+
+// Serve reads newline-delimited Requests off conn, dispatches them to
+// h, and writes back the Response, until conn is closed or an error
+// occurs. Concurrently, it forwards every Event h emits to conn as its
+// own frame, so a connected Client can observe playback and revocation
+// events as they happen rather than only when it has a command
+// in-flight. It's intended to be run in its own goroutine per accepted
+// connection, and only one Serve should run per Handler at a time since
+// h.Events() has a single consumer.
+func Serve(conn net.Conn, h *Handler) error {
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+
+	writeFrame := func(f frame) error {
+		encoded, err := json.Marshal(f)
+
+		if err != nil {
+			return err
+		}
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		_, err = conn.Write(append(encoded, '\n'))
+
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-h.Events():
+				if !ok {
+					return
+				}
+
+				if err := writeFrame(frame{Type: frameEvent, Event: &event}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err != nil {
+			return err
+		}
+
+		var req Request
+
+		if err := json.Unmarshal(line, &req); err != nil {
+			return err
+		}
+
+		resp := h.Handle(req)
+
+		if err := writeFrame(frame{Type: frameResponse, Response: &resp}); err != nil {
+			return err
+		}
+	}
+}
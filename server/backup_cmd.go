@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andytt11/one-time-plex/server/datastore"
+)
+
+// runBackupCommand implements `one-time-plex backup <dir> <out-file>`,
+// snapshotting the datastore at dir to out-file.
+func runBackupCommand(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+
+	since := flags.Uint64("since", 0, "backup version to resume from for an incremental backup")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	positional := flags.Args()
+
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: one-time-plex backup [-since N] <dir> <out-file>")
+	}
+
+	dir, outFile := positional[0], positional[1]
+
+	store, err := datastore.InitDataStore(datastore.Config{Backend: datastore.BackendBadger, Dir: dir, IsVerbose: isVerbose})
+
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	f, err := os.Create(outFile)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	version, err := store.Backup(f, *since)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("backed up %s to %s at version %d\n", dir, outFile, version)
+
+	return nil
+}
+
+// runRestoreCommand implements `one-time-plex restore <dir> <in-file>`,
+// loading a snapshot written by runBackupCommand into the datastore at dir.
+func runRestoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	positional := flags.Args()
+
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: one-time-plex restore <dir> <in-file>")
+	}
+
+	dir, inFile := positional[0], positional[1]
+
+	store, err := datastore.InitDataStore(datastore.Config{Backend: datastore.BackendBadger, Dir: dir, IsVerbose: isVerbose})
+
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	f, err := os.Open(inFile)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if err := store.Restore(f); err != nil {
+		return err
+	}
+
+	fmt.Printf("restored %s from %s\n", dir, inFile)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// ActivityType enumerates the kinds of events recorded in the activity log.
+type ActivityType string
+
+// Activity types the store can log.
+const (
+	ActivityCreation        ActivityType = "creation"
+	ActivityDeletion        ActivityType = "deletion"
+	ActivityDisabled        ActivityType = "disabled"
+	ActivityEnabled         ActivityType = "enabled"
+	ActivityPlaybackStarted ActivityType = "playback_started"
+	ActivityPlaybackStopped ActivityType = "playback_stopped"
+	ActivityAccessRevoked   ActivityType = "access_revoked"
+	ActivityTokenRefreshed  ActivityType = "token_refreshed"
+)
+
+// SourceType identifies who or what triggered an Activity.
+type SourceType string
+
+// Source types an Activity can come from.
+const (
+	SourceUser   SourceType = "user"
+	SourceAdmin  SourceType = "admin"
+	SourceAnon   SourceType = "anon"
+	SourceDaemon SourceType = "daemon"
+)
+
+// Activity records a single auditable event, such as a user being
+// created, media being assigned, or access being revoked.
+type Activity struct {
+	ID         string       `json:"id"`
+	Type       ActivityType `json:"type"`
+	UserID     string       `json:"userID"`
+	SourceType SourceType   `json:"sourceType"`
+	Source     string       `json:"source"`
+	Value      string       `json:"value"`
+	Timestamp  time.Time    `json:"timestamp"`
+}
+
+// ActivityFilter narrows the results returned by GetActivities. A zero
+// value field is not filtered on.
+type ActivityFilter struct {
+	Type   ActivityType
+	UserID string
+}
+
+// matches reports whether activity satisfies f.
+func (f ActivityFilter) matches(activity Activity) bool {
+	if f.Type != "" && activity.Type != f.Type {
+		return false
+	}
+
+	if f.UserID != "" && activity.UserID != f.UserID {
+		return false
+	}
+
+	return true
+}
+
+// newActivityID returns a random, hex-encoded activity ID.
+func newActivityID() (string, error) {
+	buf := make([]byte, 12)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
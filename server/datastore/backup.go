@@ -0,0 +1,101 @@
+package datastore
+
+import (
+	"encoding/json"
+	"io"
+
+	plex "github.com/jrudio/go-plex-client"
+)
+
+// backupEnvelope is the full-snapshot format used by genericBackup for
+// backends without Badger's native incremental Backup/Load. It covers
+// everything the store holds: secret, token, server, users, pin, and
+// activities.
+type backupEnvelope struct {
+	Secret     []byte            `json:"secret"`
+	PlexToken  string            `json:"plexToken"`
+	PlexServer Server            `json:"plexServer"`
+	PlexPin    *plex.PinResponse `json:"plexPin,omitempty"`
+	Users      map[string]User   `json:"users"`
+	Activities []Activity        `json:"activities"`
+}
+
+// genericBackup writes a full JSON snapshot of store to w. It always
+// takes a full snapshot, so the returned version is always 0 -
+// incremental backups are a Badger-specific capability.
+func genericBackup(store Store, w io.Writer) (uint64, error) {
+	plexToken, _ := store.GetPlexToken()
+	plexServer, _ := store.GetPlexServer()
+	users, err := store.GetAllUsers()
+
+	if err != nil {
+		return 0, err
+	}
+
+	activities, err := store.GetActivities(ActivityFilter{})
+
+	if err != nil {
+		return 0, err
+	}
+
+	env := backupEnvelope{
+		Secret:     store.GetSecret(),
+		PlexToken:  plexToken,
+		PlexServer: plexServer,
+		Users:      users,
+		Activities: activities,
+	}
+
+	if plexPin, err := store.GetPlexPin(); err == nil {
+		env.PlexPin = &plexPin
+	}
+
+	return 0, json.NewEncoder(w).Encode(env)
+}
+
+// genericRestore reverses genericBackup, loading a snapshot from r into store.
+func genericRestore(store Store, r io.Reader) error {
+	var env backupEnvelope
+
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return err
+	}
+
+	if err := store.SaveSecret(env.Secret); err != nil {
+		return err
+	}
+
+	if env.PlexToken != "" {
+		if err := store.SavePlexToken(env.PlexToken); err != nil {
+			return err
+		}
+	}
+
+	if err := store.SavePlexServer(env.PlexServer); err != nil {
+		return err
+	}
+
+	if env.PlexPin != nil {
+		if err := store.SavePlexPin(*env.PlexPin); err != nil {
+			return err
+		}
+	}
+
+	users := make([]User, 0, len(env.Users))
+
+	for _, user := range env.Users {
+		users = append(users, user)
+	}
+
+	if err := store.SaveUsers(users); err != nil {
+		return err
+	}
+
+	for _, activity := range env.Activities {
+		if err := store.LogActivity(activity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	plex "github.com/jrudio/go-plex-client"
+)
+
+// TestGenericBackupRestoreRoundTripsPlexPin guards the chunk0-6 fix: a
+// saved Plex pin must survive a backup/restore round trip on the
+// backends that use genericBackup/genericRestore, not just BadgerStore.
+func TestGenericBackupRestoreRoundTripsPlexPin(t *testing.T) {
+	src := NewMemoryStore(false)
+
+	var savedPin plex.PinResponse
+
+	if err := src.SavePlexPin(savedPin); err != nil {
+		t.Fatalf("SavePlexPin: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if _, err := src.Backup(&buf, 0); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	dst := NewMemoryStore(false)
+
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	got, err := dst.GetPlexPin()
+
+	if err != nil {
+		t.Fatalf("expected the restored store to have a saved plex pin: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, savedPin) {
+		t.Fatalf("plex pin did not round-trip, got %+v want %+v", got, savedPin)
+	}
+}
@@ -0,0 +1,736 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	plex "github.com/jrudio/go-plex-client"
+)
+
+// BadgerStore is the original on-disk Store backend, backed by BadgerDB.
+type BadgerStore struct {
+	db       *badger.DB
+	isClosed bool
+	keys     storeKeys
+	Secret   []byte
+}
+
+// storeKeys keys for the database
+type storeKeys struct {
+	appSecret  []byte
+	plexToken  []byte
+	plexPin    []byte
+	plexServer []byte
+	userPrefix []byte
+	allUsers   []byte
+
+	activityPrefix       []byte
+	activityByTypePrefix []byte
+	activityByUserPrefix []byte
+}
+
+// NewBadgerStore opens (or creates) a BadgerStore rooted at dirName.
+func NewBadgerStore(dirName string, _isVerbose bool) (*BadgerStore, error) {
+	var db BadgerStore
+
+	if isVerbose {
+		isVerbose = _isVerbose
+		fmt.Println("checking if our datastore exists in the home directory at:", dirName)
+	}
+
+	// create a directory for our database
+	if _, err := os.Stat(dirName); os.IsNotExist(err) {
+		if isVerbose {
+			fmt.Println("creating directory because it doesn't exist")
+		}
+
+		if err := os.Mkdir(dirName, os.ModePerm); err != nil {
+			return &db, err
+		}
+	} else if !os.IsNotExist(err) && isVerbose {
+		fmt.Println("datastore exists")
+	}
+
+	options := badger.DefaultOptions
+
+	options.Dir = dirName
+	options.ValueDir = dirName
+
+	kvStore, err := badger.Open(options)
+
+	if err != nil {
+		return &db, err
+	}
+
+	if isVerbose {
+		fmt.Println("successfully opened data store")
+	}
+
+	db.db = kvStore
+	db.keys = storeKeys{
+		appSecret:  []byte("app-secret"),
+		plexToken:  []byte("plex-token"),
+		plexPin:    []byte("plex-pin"),
+		plexServer: []byte("plex-server"),
+		userPrefix: []byte("user-"), // holds the user info
+		allUsers:   []byte("users"), // contains all user keys
+
+		activityPrefix:       []byte("activity-record-"), // holds the activity record
+		activityByTypePrefix: []byte("activity-type-"),   // secondary index by type
+		activityByUserPrefix: []byte("activity-user-"),   // secondary index by user
+	}
+
+	return &db, nil
+}
+
+// Close closes the datastore
+func (s *BadgerStore) Close() {
+	if s.isClosed {
+		fmt.Println("datastore already closed")
+		return
+	}
+
+	if err := s.db.Close(); err != nil {
+		fmt.Printf("datastore failed to closed: %v\n", err)
+	}
+
+	if isVerbose {
+		fmt.Println("datastore is closed")
+	}
+
+	s.isClosed = true
+}
+
+// GetSecret fetches app secret
+func (s *BadgerStore) GetSecret() []byte {
+	var secret []byte
+
+	// an error is returned when the key is not found
+	// so just return an empty secret
+	s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.keys.appSecret)
+
+		if err != nil {
+			return err
+		}
+
+		_secret, err := item.Value()
+
+		if err != nil {
+			return err
+		}
+
+		secret = _secret
+
+		return nil
+	})
+
+	return secret
+}
+
+// SaveSecret saves the app secret
+func (s *BadgerStore) SaveSecret(secret []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.keys.appSecret, secret, 0)
+	})
+}
+
+// GetPlexToken fetch and decrypt plex token
+func (s *BadgerStore) GetPlexToken() (string, error) {
+	var plexToken string
+
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.keys.plexToken)
+
+		if err != nil {
+			return err
+		}
+
+		tokenHash, err := item.Value()
+
+		if err != nil {
+			return err
+		}
+
+		_plexToken, err := decrypt(s.Secret, string(tokenHash))
+
+		if err != nil {
+			if isVerbose {
+				fmt.Println("token decryption failed")
+			}
+			return err
+		}
+
+		plexToken = _plexToken
+
+		return nil
+	}); err != nil {
+		return plexToken, err
+	}
+
+	if isVerbose {
+		fmt.Printf("Your plex token is %s\n", plexToken)
+	}
+
+	return plexToken, nil
+}
+
+// SavePlexToken encrypt and save plex token in datastore
+func (s *BadgerStore) SavePlexToken(token string) error {
+	tokenHash, err := encrypt(s.Secret, token)
+
+	if err != nil {
+		return err
+	}
+
+	if isVerbose {
+		fmt.Printf("your plex token hash: %s\n", string(tokenHash))
+	}
+
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.keys.plexToken, []byte(tokenHash), 0)
+	}); err != nil {
+		return err
+	}
+
+	if isVerbose {
+		fmt.Println("saved token hash to store")
+	}
+
+	return nil
+}
+
+// GetPlexPin retrieves plex pin if one was saved
+// returns error if not found
+func (s *BadgerStore) GetPlexPin() (plex.PinResponse, error) {
+	var plexPin plex.PinResponse
+
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.keys.plexPin)
+
+		if err != nil {
+			return err
+		}
+
+		plexPinBytes, err := item.Value()
+
+		if err != nil {
+			return err
+		}
+
+		var plexPinResponse plex.PinResponse
+
+		if err := json.Unmarshal(plexPinBytes, &plexPinResponse); err != nil {
+			return err
+		}
+
+		plexPin = plexPinResponse
+
+		return nil
+	}); err != nil {
+		return plexPin, err
+	}
+
+	return plexPin, nil
+}
+
+// SavePlexPin save plex pin response in datastore
+func (s *BadgerStore) SavePlexPin(plexPin plex.PinResponse) error {
+	plexPinByte, err := json.Marshal(plexPin)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.keys.plexPin, plexPinByte, 0)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClearPlexPin clear plex pin from our store
+func (s *BadgerStore) ClearPlexPin() error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(s.keys.plexPin)
+	})
+}
+
+// GetPlexServer fetches a plex server stored in the datastore
+func (s *BadgerStore) GetPlexServer() (Server, error) {
+	var plexServer Server
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.keys.plexServer)
+
+		if err != nil {
+			return err
+		}
+
+		serializedServer, err := item.Value()
+
+		if err != nil {
+			return err
+		}
+
+		_plexServer, err := UnserializeServer(serializedServer)
+
+		if err != nil {
+			return err
+		}
+
+		plexServer = _plexServer
+
+		return nil
+	})
+
+	return plexServer, err
+}
+
+// SavePlexServer saves plex server info in the datastore
+func (s *BadgerStore) SavePlexServer(plexServer Server) error {
+	serializedServer, err := plexServer.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.keys.plexServer, serializedServer, 0)
+	})
+}
+
+// userEnvelope is the versioned, encrypted-at-rest wrapper persisted
+// for every User record, so a future change to how users are encrypted
+// can tell old records apart from new ones.
+type userEnvelope struct {
+	Version    int    `json:"version"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+const userEnvelopeVersion = 1
+
+// encryptUser serializes and encrypts user with secret, ready to write
+// to Badger.
+func encryptUser(secret []byte, user User) ([]byte, error) {
+	serializedUser, err := user.Serialize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encrypt(secret, string(serializedUser))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(userEnvelope{Version: userEnvelopeVersion, Ciphertext: ciphertext})
+}
+
+// decryptUser reverses encryptUser, decrypting envelope with secret.
+func decryptUser(secret []byte, envelope []byte) (User, error) {
+	var env userEnvelope
+
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return User{}, err
+	}
+
+	serializedUser, err := decrypt(secret, env.Ciphertext)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	return UnserializeUser([]byte(serializedUser))
+}
+
+// SaveUser saves a user, encrypted at rest with s.Secret
+func (s *BadgerStore) SaveUser(user User) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		key := append(s.keys.userPrefix, []byte(user.PlexUserID)...)
+
+		encryptedUser, err := encryptUser(s.Secret, user)
+
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(key, encryptedUser, 0)
+	})
+}
+
+// SaveUsers saves multiple users, encrypted at rest with s.Secret
+func (s *BadgerStore) SaveUsers(users []User) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, user := range users {
+			key := append(s.keys.userPrefix, []byte(user.PlexUserID)...)
+
+			if isVerbose {
+				fmt.Println("saveusers key:", string(key))
+			}
+
+			encryptedUser, err := encryptUser(s.Secret, user)
+
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set(key, encryptedUser, 0); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// badgerTTLGrace is added on top of a user's app-level TTL before it's
+// handed to Badger's own SetWithTTL. Without it, Badger would purge the
+// key at the exact instant ExpiresAt elapses, racing RunReaper's next
+// tick and skipping the RevokeAccess + ActivityAccessRevoked step
+// entirely. The grace period gives the reaper a window to run first;
+// Badger remains a backstop that deletes the key even if the reaper
+// never gets to.
+const badgerTTLGrace = 1 * time.Hour
+
+// SaveUserWithTTL saves user, encrypted at rest with s.Secret, stamps
+// its ExpiresAt at now+ttl, and asks Badger to expire the key at
+// ttl+badgerTTLGrace so RunReaper has a chance to revoke access first.
+func (s *BadgerStore) SaveUserWithTTL(user User, ttl time.Duration) error {
+	user.ExpiresAt = time.Now().Add(ttl)
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		key := append(s.keys.userPrefix, []byte(user.PlexUserID)...)
+
+		encryptedUser, err := encryptUser(s.Secret, user)
+
+		if err != nil {
+			return err
+		}
+
+		return txn.SetWithTTL(key, encryptedUser, ttl+badgerTTLGrace)
+	})
+}
+
+// GetUser fetches and decrypts a user via id
+func (s *BadgerStore) GetUser(id string) (User, error) {
+	var user User
+
+	if err := s.db.View(func(txn *badger.Txn) error {
+		key := append(s.keys.userPrefix, []byte(id)...)
+
+		item, err := txn.Get(key)
+
+		if err != nil {
+			return err
+		}
+
+		encryptedUser, err := item.Value()
+
+		if err != nil {
+			return err
+		}
+
+		_user, err := decryptUser(s.Secret, encryptedUser)
+
+		if err != nil {
+			return err
+		}
+
+		user = _user
+
+		return nil
+
+	}); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// GetAllUsers fetches and decrypts all plex users that are assigned to media
+func (s *BadgerStore) GetAllUsers() (map[string]User, error) {
+	users := map[string]User{}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		prefix := s.keys.userPrefix
+
+		for it.Seek(prefix); it.Valid(); it.Next() {
+			item := it.Item()
+
+			if isVerbose {
+				fmt.Println(string(item.Key()))
+			}
+
+			encryptedUser, err := item.Value()
+
+			if err != nil {
+				return err
+			}
+
+			user, err := decryptUser(s.Secret, encryptedUser)
+
+			if err != nil {
+				return err
+			}
+
+			users[user.PlexUserID] = user
+		}
+
+		return nil
+	})
+
+	return users, err
+}
+
+// Backup streams every key added since version using Badger's own
+// backup format, so the whole datastore - secret, token, server,
+// users, pins - can be snapshotted to a single file and restored on
+// another host. The returned version is passed as since to take a
+// cheaper incremental backup next time.
+func (s *BadgerStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	return s.db.Backup(w, since)
+}
+
+// Restore loads a Backup snapshot (or concatenated chain of
+// incremental ones) from r into the datastore.
+func (s *BadgerStore) Restore(r io.Reader) error {
+	return s.db.Load(r)
+}
+
+// RotateSecret re-encrypts every user record under oldSecret with
+// newSecret inside a single Badger transaction. Callers are responsible
+// for persisting newSecret via SaveSecret and updating s.Secret
+// afterwards.
+func (s *BadgerStore) RotateSecret(oldSecret, newSecret []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		prefix := s.keys.userPrefix
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			key := append([]byte{}, item.Key()...)
+
+			encryptedUser, err := item.Value()
+
+			if err != nil {
+				return err
+			}
+
+			user, err := decryptUser(oldSecret, encryptedUser)
+
+			if err != nil {
+				return err
+			}
+
+			reencryptedUser, err := encryptUser(newSecret, user)
+
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set(key, reencryptedUser, 0); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteUser removes a user from the datastore
+func (s *BadgerStore) DeleteUser(id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		key := append(s.keys.userPrefix, []byte(id)...)
+
+		return txn.Delete(key)
+	})
+
+	return err
+}
+
+// DeleteUsers removes multiple users from the datastore
+func (s *BadgerStore) DeleteUsers(userIDs []string) error {
+	idLen := len(userIDs)
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for i := 0; i < idLen; i++ {
+			key := append(s.keys.userPrefix, []byte(userIDs[i])...)
+
+			if err := txn.Delete(key); err != nil {
+				fmt.Printf("failed to delete user id %s: %v\n", userIDs[i], err)
+				continue
+			}
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// LogActivity records activity in the datastore, assigning it an ID if
+// it doesn't already have one, and indexing it by type and by user so
+// GetActivities can filter efficiently.
+func (s *BadgerStore) LogActivity(activity Activity) error {
+	if activity.ID == "" {
+		id, err := newActivityID()
+
+		if err != nil {
+			return err
+		}
+
+		activity.ID = id
+	}
+
+	serializedActivity, err := json.Marshal(activity)
+
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		key := append(s.keys.activityPrefix, []byte(activity.ID)...)
+
+		if err := txn.Set(key, serializedActivity, 0); err != nil {
+			return err
+		}
+
+		byType := append(append([]byte{}, s.keys.activityByTypePrefix...), []byte(string(activity.Type)+"-"+activity.ID)...)
+
+		if err := txn.Set(byType, []byte(activity.ID), 0); err != nil {
+			return err
+		}
+
+		byUser := append(append([]byte{}, s.keys.activityByUserPrefix...), []byte(activity.UserID+"-"+activity.ID)...)
+
+		return txn.Set(byUser, []byte(activity.ID), 0)
+	})
+}
+
+// getActivityByID fetches a single Activity record by its ID.
+func (s *BadgerStore) getActivityByID(txn *badger.Txn, id string) (Activity, error) {
+	var activity Activity
+
+	key := append(s.keys.activityPrefix, []byte(id)...)
+
+	item, err := txn.Get(key)
+
+	if err != nil {
+		return activity, err
+	}
+
+	serializedActivity, err := item.Value()
+
+	if err != nil {
+		return activity, err
+	}
+
+	err = json.Unmarshal(serializedActivity, &activity)
+
+	return activity, err
+}
+
+// GetActivities fetches activities matching filter. An empty filter
+// returns every logged activity.
+func (s *BadgerStore) GetActivities(filter ActivityFilter) ([]Activity, error) {
+	var activities []Activity
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		// an index prefix narrows the scan to ids matching one field of
+		// the filter; walking the primary records directly is the
+		// fallback for an unfiltered query.
+		indexPrefix, usingIndex := s.indexPrefixFor(filter)
+
+		if !usingIndex {
+			for it.Seek(s.keys.activityPrefix); it.ValidForPrefix(s.keys.activityPrefix); it.Next() {
+				serializedActivity, err := it.Item().Value()
+
+				if err != nil {
+					return err
+				}
+
+				var activity Activity
+
+				if err := json.Unmarshal(serializedActivity, &activity); err != nil {
+					return err
+				}
+
+				if filter.matches(activity) {
+					activities = append(activities, activity)
+				}
+			}
+
+			return nil
+		}
+
+		for it.Seek(indexPrefix); it.ValidForPrefix(indexPrefix); it.Next() {
+			id, err := it.Item().Value()
+
+			if err != nil {
+				return err
+			}
+
+			activity, err := s.getActivityByID(txn, string(id))
+
+			if err != nil {
+				return err
+			}
+
+			if filter.matches(activity) {
+				activities = append(activities, activity)
+			}
+		}
+
+		return nil
+	})
+
+	return activities, err
+}
+
+// indexPrefixFor picks the narrowest secondary index to scan for
+// filter, reporting false when no field narrows the scan.
+func (s *BadgerStore) indexPrefixFor(filter ActivityFilter) ([]byte, bool) {
+	if filter.UserID != "" {
+		return append(append([]byte{}, s.keys.activityByUserPrefix...), []byte(filter.UserID+"-")...), true
+	}
+
+	if filter.Type != "" {
+		return append(append([]byte{}, s.keys.activityByTypePrefix...), []byte(string(filter.Type)+"-")...), true
+	}
+
+	return nil, false
+}
+
+// GetActivitiesForUser fetches every activity logged against id.
+func (s *BadgerStore) GetActivitiesForUser(id string) ([]Activity, error) {
+	return s.GetActivities(ActivityFilter{UserID: id})
+}
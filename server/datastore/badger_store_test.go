@@ -0,0 +1,100 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+func newTestBadgerStore(t *testing.T) *BadgerStore {
+	t.Helper()
+
+	store, err := NewBadgerStore(t.TempDir(), false)
+
+	if err != nil {
+		t.Fatalf("NewBadgerStore: %v", err)
+	}
+
+	t.Cleanup(store.Close)
+
+	store.Secret = []byte("test-secret")
+
+	return store
+}
+
+// ttlRemaining returns the Badger-level TTL left on a user's key, or
+// zero if the key carries no expiry at all.
+func ttlRemaining(t *testing.T, store *BadgerStore, userID string) time.Duration {
+	t.Helper()
+
+	var remaining time.Duration
+
+	err := store.db.View(func(txn *badger.Txn) error {
+		key := append(store.keys.userPrefix, []byte(userID)...)
+
+		item, err := txn.Get(key)
+
+		if err != nil {
+			return err
+		}
+
+		expiresAt := item.ExpiresAt()
+
+		if expiresAt == 0 {
+			remaining = 0
+			return nil
+		}
+
+		remaining = time.Until(time.Unix(int64(expiresAt), 0))
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("reading back ttl: %v", err)
+	}
+
+	return remaining
+}
+
+// TestSaveUserWithTTLThenReapPreservesBackstop guards the chunk0-5
+// regression: revoking an expired user during a reaper pass must not
+// erase Badger's own TTL backstop. If the reaper ever goes back to a
+// plain SaveUser, this fails because the revoked record would carry no
+// expiry at all.
+func TestSaveUserWithTTLThenReapPreservesBackstop(t *testing.T) {
+	store := newTestBadgerStore(t)
+
+	user := User{PlexUserID: "user-1"}
+
+	if err := store.SaveUserWithTTL(user, -time.Minute); err != nil {
+		t.Fatalf("SaveUserWithTTL: %v", err)
+	}
+
+	if remaining := ttlRemaining(t, store, user.PlexUserID); remaining <= 0 {
+		t.Fatalf("expected a positive TTL backstop right after save, got %v", remaining)
+	}
+
+	reapExpiredUsers(store)
+
+	got, err := store.GetUser(user.PlexUserID)
+
+	if err != nil {
+		t.Fatalf("GetUser after reap: %v", err)
+	}
+
+	if !got.RevokeAccess {
+		t.Fatalf("expected RevokeAccess to be set after the reaper's first pass")
+	}
+
+	if remaining := ttlRemaining(t, store, user.PlexUserID); remaining <= 0 {
+		t.Fatalf("revoke pass erased the Badger TTL backstop, got %v", remaining)
+	}
+
+	reapExpiredUsers(store)
+
+	if _, err := store.GetUser(user.PlexUserID); err == nil {
+		t.Fatalf("expected the second reaper pass to delete the revoked user")
+	}
+}
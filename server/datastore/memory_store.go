@@ -0,0 +1,279 @@
+package datastore
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	plex "github.com/jrudio/go-plex-client"
+)
+
+// MemoryStore is an in-process Store backend with no disk footprint.
+// It mirrors the NewMemoryTokenStore pattern: useful for tests and for
+// deployments that don't need the data to survive a restart.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	secret     []byte
+	plexToken  string
+	plexPin    plex.PinResponse
+	hasPlexPin bool
+	plexServer Server
+	users      map[string]User
+	activities []Activity
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore(_isVerbose bool) *MemoryStore {
+	isVerbose = _isVerbose
+
+	return &MemoryStore{
+		users: map[string]User{},
+	}
+}
+
+// Close is a no-op for MemoryStore; there's nothing to release.
+func (s *MemoryStore) Close() {
+	if isVerbose {
+		fmt.Println("memory datastore is closed")
+	}
+}
+
+// GetSecret fetches app secret
+func (s *MemoryStore) GetSecret() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.secret
+}
+
+// SaveSecret saves the app secret
+func (s *MemoryStore) SaveSecret(secret []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secret = secret
+
+	return nil
+}
+
+// GetPlexToken fetch and decrypt plex token
+func (s *MemoryStore) GetPlexToken() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.plexToken == "" {
+		return "", fmt.Errorf("plex token not found")
+	}
+
+	return s.plexToken, nil
+}
+
+// SavePlexToken save plex token in datastore
+func (s *MemoryStore) SavePlexToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.plexToken = token
+
+	return nil
+}
+
+// GetPlexPin retrieves plex pin if one was saved
+// returns error if not found
+func (s *MemoryStore) GetPlexPin() (plex.PinResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.hasPlexPin {
+		return plex.PinResponse{}, fmt.Errorf("plex pin not found")
+	}
+
+	return s.plexPin, nil
+}
+
+// SavePlexPin save plex pin response in datastore
+func (s *MemoryStore) SavePlexPin(plexPin plex.PinResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.plexPin = plexPin
+	s.hasPlexPin = true
+
+	return nil
+}
+
+// ClearPlexPin clear plex pin from our store
+func (s *MemoryStore) ClearPlexPin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.plexPin = plex.PinResponse{}
+	s.hasPlexPin = false
+
+	return nil
+}
+
+// GetPlexServer fetches a plex server stored in the datastore
+func (s *MemoryStore) GetPlexServer() (Server, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.plexServer, nil
+}
+
+// SavePlexServer saves plex server info in the datastore
+func (s *MemoryStore) SavePlexServer(plexServer Server) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.plexServer = plexServer
+
+	return nil
+}
+
+// SaveUser saves a user
+func (s *MemoryStore) SaveUser(user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.PlexUserID] = user
+
+	return nil
+}
+
+// SaveUsers saves multiple users
+func (s *MemoryStore) SaveUsers(users []User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range users {
+		s.users[user.PlexUserID] = user
+	}
+
+	return nil
+}
+
+// SaveUserWithTTL saves user the way SaveUser does, stamping its
+// ExpiresAt so RunReaper can expire it; MemoryStore has no native TTL.
+func (s *MemoryStore) SaveUserWithTTL(user User, ttl time.Duration) error {
+	user.ExpiresAt = time.Now().Add(ttl)
+
+	return s.SaveUser(user)
+}
+
+// GetUser fetches a user via id
+func (s *MemoryStore) GetUser(id string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+
+	if !ok {
+		return User{}, fmt.Errorf("user %s not found", id)
+	}
+
+	return user, nil
+}
+
+// GetAllUsers fetches all plex users that are assigned to media
+func (s *MemoryStore) GetAllUsers() (map[string]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(map[string]User, len(s.users))
+
+	for id, user := range s.users {
+		users[id] = user
+	}
+
+	return users, nil
+}
+
+// DeleteUser removes a user from the datastore
+func (s *MemoryStore) DeleteUser(id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, id)
+
+	return nil
+}
+
+// DeleteUsers removes multiple users from the datastore
+func (s *MemoryStore) DeleteUsers(userIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range userIDs {
+		delete(s.users, id)
+	}
+
+	return nil
+}
+
+// LogActivity records activity, assigning it an ID if it doesn't
+// already have one.
+func (s *MemoryStore) LogActivity(activity Activity) error {
+	if activity.ID == "" {
+		id, err := newActivityID()
+
+		if err != nil {
+			return err
+		}
+
+		activity.ID = id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.activities = append(s.activities, activity)
+
+	return nil
+}
+
+// GetActivities fetches activities matching filter. An empty filter
+// returns every logged activity.
+func (s *MemoryStore) GetActivities(filter ActivityFilter) ([]Activity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var activities []Activity
+
+	for _, activity := range s.activities {
+		if filter.matches(activity) {
+			activities = append(activities, activity)
+		}
+	}
+
+	return activities, nil
+}
+
+// GetActivitiesForUser fetches every activity logged against id.
+func (s *MemoryStore) GetActivitiesForUser(id string) ([]Activity, error) {
+	return s.GetActivities(ActivityFilter{UserID: id})
+}
+
+// RotateSecret is a no-op: MemoryStore never persists to disk, so
+// there's nothing encrypted at rest to re-encrypt.
+func (s *MemoryStore) RotateSecret(oldSecret, newSecret []byte) error {
+	return nil
+}
+
+// Backup writes a full JSON snapshot of the store to w. MemoryStore has
+// no backup version counter, so since is ignored and the returned
+// version is always 0.
+func (s *MemoryStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	return genericBackup(s, w)
+}
+
+// Restore loads a Backup snapshot from r into the store.
+func (s *MemoryStore) Restore(r io.Reader) error {
+	return genericRestore(s, r)
+}
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RunReaper periodically scans store for users whose
+// AssignedMedia.ExpiresAt has passed. The first time it finds an expired, still-active user it
+// sets RevokeAccess and logs an AccessRevoked activity; once a user is
+// both expired and already revoked, it deletes the record. It blocks
+// until ctx is canceled, so callers should run it in its own goroutine.
+func RunReaper(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reapExpiredUsers(store)
+		}
+	}
+}
+
+// reapExpiredUsers runs a single reaper pass.
+func reapExpiredUsers(store Store) {
+	users, err := store.GetAllUsers()
+
+	if err != nil {
+		fmt.Printf("reaper: failed to list users: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, user := range users {
+		if user.ExpiresAt.IsZero() || user.ExpiresAt.After(now) {
+			continue
+		}
+
+		if user.RevokeAccess {
+			if err := store.DeleteUser(user.PlexUserID); err != nil {
+				fmt.Printf("reaper: failed to delete expired user %s: %v\n", user.PlexUserID, err)
+			}
+
+			continue
+		}
+
+		user.RevokeAccess = true
+
+		// Use SaveUserWithTTL, not SaveUser, so BadgerStore's TTL
+		// backstop survives the revoke step: a plain SaveUser persists
+		// with no expiry at all, which would mean a crash between this
+		// tick and the delete tick leaves the now-revoked record on disk
+		// forever. Any remaining TTL is re-derived from ExpiresAt itself
+		// (already elapsed here, so it floors at zero) purely so the
+		// backend still attaches its own backstop expiry.
+		remaining := time.Until(user.ExpiresAt)
+
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		if err := store.SaveUserWithTTL(user, remaining); err != nil {
+			fmt.Printf("reaper: failed to revoke expired user %s: %v\n", user.PlexUserID, err)
+			continue
+		}
+
+		if err := store.LogActivity(Activity{
+			Type:       ActivityAccessRevoked,
+			UserID:     user.PlexUserID,
+			SourceType: SourceDaemon,
+			Source:     "reaper",
+			Value:      "assigned media TTL expired",
+			Timestamp:  now,
+		}); err != nil {
+			fmt.Printf("reaper: failed to log activity for user %s: %v\n", user.PlexUserID, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLStore(t *testing.T) *SQLStore {
+	t.Helper()
+
+	dsn := "sqlite3:" + filepath.Join(t.TempDir(), "one-time-plex.db")
+
+	store, err := NewSQLStore(dsn, false)
+
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+
+	t.Cleanup(store.Close)
+
+	store.Secret = []byte("test-secret")
+
+	return store
+}
+
+// TestSQLStoreUserDataIsEncryptedAtRest guards the chunk0-4 fix: a user
+// record read straight out of the users table must not contain the
+// plaintext serialized user, the way BadgerStore's records don't.
+func TestSQLStoreUserDataIsEncryptedAtRest(t *testing.T) {
+	store := newTestSQLStore(t)
+
+	user := User{PlexUserID: "user-1"}
+
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+
+	var data string
+
+	row := store.db.QueryRow(store.q("SELECT data FROM users WHERE id = ?"), user.PlexUserID)
+
+	if err := row.Scan(&data); err != nil {
+		t.Fatalf("reading back raw data column: %v", err)
+	}
+
+	serializedUser, err := user.Serialize()
+
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+
+	if data == string(serializedUser) {
+		t.Fatalf("user data column holds the plaintext serialized user, want ciphertext")
+	}
+
+	got, err := store.GetUser(user.PlexUserID)
+
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	if got.PlexUserID != user.PlexUserID {
+		t.Fatalf("GetUser did not round-trip the user, got %+v", got)
+	}
+}
+
+// TestSQLStoreRotateSecretReencryptsUsers guards RotateSecret actually
+// re-encrypting every user row instead of being a no-op: a user saved
+// under the old secret must still read back correctly once s.Secret is
+// updated to the new one.
+func TestSQLStoreRotateSecretReencryptsUsers(t *testing.T) {
+	store := newTestSQLStore(t)
+
+	oldSecret := store.Secret
+	newSecret := []byte("rotated-secret")
+
+	user := User{PlexUserID: "user-1"}
+
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+
+	if err := store.RotateSecret(oldSecret, newSecret); err != nil {
+		t.Fatalf("RotateSecret: %v", err)
+	}
+
+	store.Secret = newSecret
+
+	got, err := store.GetUser(user.PlexUserID)
+
+	if err != nil {
+		t.Fatalf("GetUser after RotateSecret: %v", err)
+	}
+
+	if got.PlexUserID != user.PlexUserID {
+		t.Fatalf("user did not survive RotateSecret, got %+v", got)
+	}
+
+	store.Secret = oldSecret
+
+	if _, err := store.GetUser(user.PlexUserID); err == nil {
+		t.Fatalf("expected decrypting with the old secret to fail after rotation")
+	}
+}